@@ -7,9 +7,10 @@
 package pdf
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 )
 
@@ -32,14 +33,55 @@ type name string
 // such as "<<", ">>", "[", "]", "{", "}", are also treated as keywords.
 type keyword string
 
-// A buffer holds buffered input bytes from the PDF file.
+// An ErrorKind classifies a ParseError, so callers can distinguish a
+// malformed hex string from, say, a missing endobj without parsing Msg.
+type ErrorKind int
+
+const (
+	ErrOther ErrorKind = iota
+	ErrRead
+	ErrMalformedHexString
+	ErrInvalidEscape
+	ErrMalformedOctalEscape
+	ErrMalformedName
+	ErrUnexpectedDelimiter
+	ErrInvalidNumber
+	ErrNonNameKey
+	ErrMissingEndobj
+	ErrMissingStreamNewline
+)
+
+// A ParseError describes a malformed-PDF condition encountered while
+// reading a token or object. Offset is the byte offset in the underlying
+// file where the problem was found; Ptr is the indirect object (if any)
+// being parsed at the time, with Ptr.id == 0 meaning "outside any object".
+type ParseError struct {
+	Offset int64
+	Ptr    objptr
+	Kind   ErrorKind
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	if e.Ptr.id != 0 {
+		return fmt.Sprintf("offset %d (obj %d %d): %s", e.Offset, e.Ptr.id, e.Ptr.gen, e.Msg)
+	}
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Msg)
+}
+
+// A buffer holds buffered input bytes from the PDF file, addressed by
+// absolute file offset through a pageCache rather than a sliding window.
+// This lets callers seek freely (cheaply) and lets independent buffers
+// over the same pageCache parse different regions of a large PDF
+// concurrently, e.g. for per-page extraction.
 type buffer struct {
-	r           io.Reader // source of data
-	buf         []byte    // buffered data
-	pos         int       // read index in buf
-	offset      int64     // offset at end of buf; aka offset of next read
-	tmp         []byte    // scratch space for accumulating token
-	unread      []token   // queue of read but then unread tokens
+	cache *pageCache
+	pos   int64 // absolute offset of the next byte readByte will return
+
+	file *os.File // non-nil when newBuffer owns a temp file backing cache; see Close
+
+	tmp         []byte  // scratch space for accumulating token
+	unread      []token // queue of read but then unread tokens
 	allowEOF    bool
 	allowObjptr bool
 	allowStream bool
@@ -47,89 +89,126 @@ type buffer struct {
 	key         []byte
 	useAES      bool
 	objptr      objptr
+
+	// strict, when true, makes recordError return the ParseError so the
+	// caller can abort parsing immediately instead of limping onward.
+	strict bool
+	// errs accumulates every ParseError seen on this buffer, fatal or not,
+	// so a caller parsing in non-strict mode can inspect them afterward.
+	errs []*ParseError
 }
 
-// newBuffer returns a new buffer reading from r at the given offset.
-func newBuffer(r io.Reader, offset int64) *buffer {
+// newBufferReaderAt returns a new buffer reading from ra at the given
+// offset, over a fresh pageCache of its own over ra. Call this directly when ra
+// already supports random access (an *os.File, a bytes.Reader, a
+// memory-mapped mmap.ReaderAt, ...); use newBuffer for a plain
+// io.Reader.
+func newBufferReaderAt(ra io.ReaderAt, offset int64) *buffer {
 	return &buffer{
-		r:           r,
-		offset:      offset,
-		buf:         make([]byte, 0, 4096),
+		cache:       newPageCache(ra, defaultCachePages),
+		pos:         offset,
 		allowObjptr: true,
 		allowStream: true,
 	}
 }
 
-func (b *buffer) seek(offset int64) {
-	b.offset = offset
-	b.buf = b.buf[:0]
-	b.pos = 0
-	b.unread = b.unread[:0]
+// newBuffer returns a new buffer reading from r at the given offset. r is
+// only a sequential io.Reader, so newBuffer copies it into a temporary
+// file first and builds the buffer on top of that file's random access;
+// call Close when done with the buffer to remove the temp file. Callers
+// that already have an io.ReaderAt (typically an *os.File opened by the
+// caller) should use newBufferReaderAt instead and avoid the copy.
+func newBuffer(r io.Reader, offset int64) *buffer {
+	f, err := os.CreateTemp("", "pdf-buffer-*")
+	if err != nil {
+		data, _ := io.ReadAll(r)
+		return newBufferReaderAt(bytes.NewReader(data), offset)
+	}
+	os.Remove(f.Name()) // unlinked; the open fd keeps the data alive until Close
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return newBufferReaderAt(bytes.NewReader(nil), offset)
+	}
+	b := newBufferReaderAt(f, offset)
+	b.file = f
+	return b
 }
 
-func (b *buffer) readByte() (byte, error) {
-	if b.pos >= len(b.buf) {
-		rel, err := b.reload()
-		if err != nil {
-			return '\n', err
-		}
-		if !rel {
-			return '\n', err
-		}
-		if b.pos >= len(b.buf) {
-			return '\n', nil
-		}
+// Close releases any resources newBuffer allocated on b's behalf (the
+// backing temp file). It is a no-op for buffers built with
+// newBufferReaderAt, which don't own their io.ReaderAt.
+func (b *buffer) Close() error {
+	if b.file != nil {
+		return b.file.Close()
 	}
-	c := b.buf[b.pos]
-	b.pos++
-	return c, nil
+	return nil
 }
 
-func (b *buffer) errorf(format string, args ...interface{}) string {
-	return fmt.Sprintf(format, args...)
+// recordError records a malformed-PDF condition at the buffer's current
+// read offset and the indirect object (if any) currently being parsed. It
+// always appends the error to b.errs; in strict mode it also returns the
+// error so the caller can fail fast, otherwise it returns nil so the
+// caller can keep going and treat the condition as a warning.
+func (b *buffer) recordError(kind ErrorKind, format string, args ...interface{}) error {
+	e := &ParseError{
+		Offset: b.readOffset(),
+		Ptr:    b.objptr,
+		Kind:   kind,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+	b.errs = append(b.errs, e)
+	if b.strict {
+		return e
+	}
+	return nil
 }
 
-func (b *buffer) reload() (bool, error) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("Recovered. Error:\n", r)
-		}
-	}()
-	n := cap(b.buf) - int(b.offset%int64(cap(b.buf)))
-	n, err := b.r.Read(b.buf[:n])
-	if n == 0 && err != nil {
-		b.buf = b.buf[:0]
-		b.pos = 0
-		if b.allowEOF && err == io.EOF {
-			b.eof = true
-			return false, err
-		}
-		//b.errorf("malformed PDF: reading at offset %d: %v", b.offset, err)
-		fmt.Printf(b.errorf("malformed PDF: reading at offset %d: %v", b.offset, err))
-		return false, err
-	}
-	b.offset += int64(n)
-	b.buf = b.buf[:n]
-	b.pos = 0
-	return true, err
+// Warnings returns the ParseErrors accumulated on b so far. In non-strict
+// mode this includes conditions that did not stop parsing.
+func (b *buffer) Warnings() []*ParseError {
+	return b.errs
 }
 
-func (b *buffer) seekForward(offset int64) (err error) {
-	for b.offset < offset {
-		rel, err := b.reload()
-		if err != nil {
-			return err
-		}
-		if !rel {
-			return err
+func (b *buffer) seek(offset int64) {
+	b.pos = offset
+	b.unread = b.unread[:0]
+}
+
+func (b *buffer) readByte() (byte, error) {
+	p, err := b.cache.get(b.pos / pageSize)
+	if err != nil {
+		return '\n', err
+	}
+	off := int(b.pos % pageSize)
+	if off >= p.n {
+		if p.err == io.EOF {
+			if b.allowEOF {
+				b.eof = true
+				return '\n', io.EOF
+			}
+			// A read failure is fatal regardless of StrictMode: there is
+			// no way to keep parsing past missing bytes, unlike the
+			// malformed-but-present syntax recordError normally guards.
+			b.recordError(ErrRead, "malformed PDF: reading at offset %d: %v", b.pos, p.err)
+			return '\n', p.err
 		}
+		return '\n', nil
 	}
-	b.pos = len(b.buf) - int(b.offset-offset)
-	return err
+	c := p.data[off]
+	b.pos++
+	return c, nil
+}
+
+// seekForward moves the read position forward to offset, which must not
+// be before the current position. Random access makes this a plain
+// assignment; there is no incremental refill to drive.
+func (b *buffer) seekForward(offset int64) error {
+	b.pos = offset
+	return nil
 }
 
 func (b *buffer) readOffset() int64 {
-	return b.offset - int64(len(b.buf)) + int64(b.pos)
+	return b.pos
 }
 
 func (b *buffer) unreadByte() {
@@ -209,8 +288,10 @@ func (b *buffer) readToken() token {
 
 	default:
 		if isDelim(c) {
-			return b.errorf("unexpected delimiter %#q", rune(c))
-			// return nil
+			if err := b.recordError(ErrUnexpectedDelimiter, "unexpected delimiter %#q", rune(c)); err != nil {
+				return err
+			}
+			return keyword(c)
 		}
 		b.unreadByte()
 		return b.readKeyword()
@@ -241,7 +322,9 @@ func (b *buffer) readHexString() token {
 		}
 		x := unhex(c)<<4 | unhex(c2)
 		if x < 0 {
-			fmt.Printf(b.errorf("malformed hex string %c %c %s", c, c2, b.buf[b.pos:]))
+			if err := b.recordError(ErrMalformedHexString, "malformed hex string %c %c", c, c2); err != nil {
+				return err
+			}
 			break
 		}
 		tmp = append(tmp, byte(x))
@@ -288,7 +371,9 @@ Loop:
 				if err != nil {
 					return err
 				}
-				fmt.Printf(b.errorf("invalid escape sequence \\%c", c))
+				if err := b.recordError(ErrInvalidEscape, "invalid escape sequence \\%c", c); err != nil {
+					return err
+				}
 				tmp = append(tmp, '\\', c)
 			case 'n':
 				tmp = append(tmp, '\n')
@@ -327,7 +412,9 @@ Loop:
 					x = x*8 + int(c-'0')
 				}
 				if x > 255 {
-					b.errorf("invalid octal escape \\%03o", x)
+					if err := b.recordError(ErrMalformedOctalEscape, "invalid octal escape \\%03o", x); err != nil {
+						return err
+					}
 				}
 				tmp = append(tmp, byte(x))
 			}
@@ -351,12 +438,17 @@ func (b *buffer) readName() token {
 		if c == '#' {
 			d, err1 := b.readByte()
 			e, err2 := b.readByte()
-			if err1 != nil || err2 != nil {
-				return err
+			if err1 != nil {
+				return err1
+			}
+			if err2 != nil {
+				return err2
 			}
 			x := unhex(d)<<4 | unhex(e)
 			if x < 0 {
-				fmt.Printf(b.errorf("malformed name"))
+				if err := b.recordError(ErrMalformedName, "malformed name"); err != nil {
+					return err
+				}
 			}
 			tmp = append(tmp, byte(x))
 			continue
@@ -372,6 +464,14 @@ func (b *buffer) readKeyword() token {
 	for {
 		c, err := b.readByte()
 		if err != nil {
+			if err == io.EOF && len(tmp) > 0 {
+				// EOF right after the bytes that make up this keyword (no
+				// trailing delimiter) still terminates a valid token, e.g.
+				// the last scalar object in an object stream with no
+				// padding after it. Losing tmp here would turn a real
+				// value into a bare error.
+				break
+			}
 			return err
 		}
 		if isDelim(c) || isSpace(c) {
@@ -390,13 +490,17 @@ func (b *buffer) readKeyword() token {
 	case isInteger(s):
 		x, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			fmt.Printf(b.errorf("invalid integer %s", s))
+			if err := b.recordError(ErrInvalidNumber, "invalid integer %s", s); err != nil {
+				return err
+			}
 		}
 		return x
 	case isReal(s):
 		x, err := strconv.ParseFloat(s, 64)
 		if err != nil {
-			fmt.Printf(b.errorf("invalid integer %s", s))
+			if err := b.recordError(ErrInvalidNumber, "invalid real %s", s); err != nil {
+				return err
+			}
 		}
 		return x
 	}
@@ -476,16 +580,19 @@ type objdef struct {
 
 func (b *buffer) readObject() (object, error) {
 	tok := b.readToken()
+	if err, ok := tok.(error); ok {
+		return nil, err
+	}
 	if kw, ok := tok.(keyword); ok {
 		switch kw {
 		case "null":
 			return nil, nil
 		case "<<":
-			return b.readDict(), nil
+			return b.readDict()
 		case "[":
-			return b.readArray(), nil
+			return b.readArray()
 		}
-		return nil, errors.New(b.errorf("unexpected keyword %q parsing object", kw))
+		return nil, b.recordError(ErrOther, "unexpected keyword %q parsing object", kw)
 	}
 
 	if str, ok := tok.(string); ok && b.key != nil && b.objptr.id != 0 {
@@ -508,17 +615,21 @@ func (b *buffer) readObject() (object, error) {
 				b.objptr = objptr{uint32(t1), uint16(t2)}
 				obj, err := b.readObject()
 				if err != nil {
+					b.objptr = old
 					return nil, err
 				}
 				if _, ok := obj.(stream); !ok {
 					tok4 := b.readToken()
 					if tok4 != keyword("endobj") {
-						fmt.Printf(b.errorf("missing endobj after indirect object definition"))
+						if err := b.recordError(ErrMissingEndobj, "missing endobj after indirect object definition"); err != nil {
+							b.objptr = old
+							return nil, err
+						}
 						b.unreadToken(tok4)
 					}
 				}
 				b.objptr = old
-				return objdef{objptr{uint32(t1), uint16(t2)}, obj}, err
+				return objdef{objptr{uint32(t1), uint16(t2)}, obj}, nil
 			}
 			b.unreadToken(tok3)
 		}
@@ -527,77 +638,93 @@ func (b *buffer) readObject() (object, error) {
 	return tok, nil
 }
 
-func (b *buffer) readArray() object {
+func (b *buffer) readArray() (object, error) {
 	var x array
 	for {
 		tok := b.readToken()
+		if err, ok := tok.(error); ok {
+			// readToken hit EOF (or a hard read error) before the
+			// closing "]"; stop instead of looping forever re-reading
+			// the same error token.
+			return nil, err
+		}
 		if tok == nil || tok == keyword("]") {
 			break
 		}
 		b.unreadToken(tok)
 		res, err := b.readObject()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		x = append(x, res)
 	}
-	return x
+	return x, nil
 }
 
-func (b *buffer) readDict() object {
+func (b *buffer) readDict() (object, error) {
 	x := make(dict)
 	for {
 		tok := b.readToken()
+		if err, ok := tok.(error); ok {
+			// readToken hit EOF (or a hard read error) before the
+			// closing ">>"; stop instead of looping forever treating
+			// the same error token as a malformed dictionary key.
+			return nil, err
+		}
 		if tok == nil || tok == keyword(">>") {
 			break
 		}
 		n, ok := tok.(name)
 		if !ok {
-			fmt.Printf(b.errorf("unexpected non-name key %T(%v) parsing dictionary", tok, tok))
+			if err := b.recordError(ErrNonNameKey, "unexpected non-name key %T(%v) parsing dictionary", tok, tok); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		res, err := b.readObject()
 		if err != nil {
-			return nil
+			return nil, err
 		}
 		x[n] = res
 	}
 
 	if !b.allowStream {
-		return x
+		return x, nil
 	}
 
 	tok := b.readToken()
 	if tok != keyword("stream") {
 		b.unreadToken(tok)
-		return x
+		return x, nil
 	}
 
 	switch c, err := b.readByte(); c {
 	case '\r':
 		if err != nil {
-			return err
+			return nil, err
 		}
 		d, err := b.readByte()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if d != '\n' {
 			b.unreadByte()
 		}
 	case '\n':
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// ok
 	default:
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if err := b.recordError(ErrMissingStreamNewline, "stream keyword not followed by newline"); err != nil {
+			return nil, err
 		}
-		return b.errorf("stream keyword not followed by newline")
 	}
 
-	return stream{x, b.objptr, b.readOffset()}
+	return stream{x, b.objptr, b.readOffset()}, nil
 }
 
 func isSpace(b byte) bool {
@@ -0,0 +1,208 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Reading of compressed object streams (/Type /ObjStm) and
+// cross-reference streams (/Type /XRef), as introduced in PDF 1.5.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An xrefKind classifies one row of a cross-reference stream: whether the
+// object is unused, stored as a classic indirect object at a byte offset,
+// or stored inside an object stream.
+type xrefKind int
+
+const (
+	xrefFree xrefKind = iota
+	xrefClassic
+	xrefCompressed
+)
+
+// An xrefRecord is one decoded row of a cross-reference stream (or, for
+// uniformity, of a classic xref table): where to find a given object
+// number, however it is stored.
+type xrefRecord struct {
+	kind   xrefKind
+	offset int64  // for xrefClassic: byte offset of "id gen obj"
+	stmNum uint32 // for xrefCompressed: object number of the containing /ObjStm
+	stmIdx int    // for xrefCompressed: index of this object within that stream
+}
+
+// decodeStreamData returns the decoded bytes of stream s, whose raw bytes
+// (as stored in the file, before any /Filter is undone) are raw. Only
+// FlateDecode is understood; any other filter, or a filter chain, is
+// reported through recordError on b rather than failing outright, so a
+// caller in non-strict mode can still see the rest of the file.
+func decodeStreamData(b *buffer, s stream, raw []byte) ([]byte, error) {
+	f := s.hdr[name("Filter")]
+	filter, ok := f.(name)
+	if f != nil && !ok {
+		if err := b.recordError(ErrOther, "unsupported stream filter chain %v", f); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+	switch filter {
+	case "", "FlateDecode":
+		if filter == "" {
+			return raw, nil
+		}
+		zr := flate.NewReader(bytes.NewReader(raw))
+		defer zr.Close()
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, b.recordError(ErrOther, "invalid FlateDecode stream: %v", err)
+		}
+		return data, nil
+	default:
+		if err := b.recordError(ErrOther, "unsupported stream filter %q", filter); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+}
+
+// readObjStm decodes a /Type /ObjStm stream (an object stream: a run of
+// compressed indirect objects packed head to tail) into its objdefs. hdr
+// is the stream's dictionary (already read by readDict) and data is the
+// stream's decoded (uncompressed) bytes.
+//
+// The object stream format is: N pairs of "objnum offset" integers
+// (offset relative to First), then, starting at byte First, the N
+// objects themselves with no separators or "id gen obj"/"endobj"
+// wrapper - just the object values back to back.
+func readObjStm(hdr dict, data []byte) ([]objdef, error) {
+	n, ok := hdr[name("N")].(int64)
+	if !ok {
+		return nil, errors.New("pdf: object stream missing /N")
+	}
+	first, ok := hdr[name("First")].(int64)
+	if !ok {
+		return nil, errors.New("pdf: object stream missing /First")
+	}
+
+	hb := newBufferReaderAt(bytes.NewReader(data), 0)
+	hb.allowObjptr = false
+	hb.allowStream = false
+	hb.allowEOF = false
+
+	type pair struct {
+		id     uint32
+		offset int64
+	}
+	pairs := make([]pair, n)
+	for i := range pairs {
+		idTok := hb.readToken()
+		offTok := hb.readToken()
+		id, ok1 := idTok.(int64)
+		off, ok2 := offTok.(int64)
+		if !ok1 || !ok2 {
+			return nil, errors.New("pdf: malformed object stream header")
+		}
+		pairs[i] = pair{uint32(id), off}
+	}
+
+	defs := make([]objdef, n)
+	for i, p := range pairs {
+		start := first + p.offset
+		if start < 0 || start > int64(len(data)) {
+			return nil, fmt.Errorf("pdf: object stream member %d offset %d out of range (stream is %d bytes)", p.id, start, len(data))
+		}
+		ob := newBufferReaderAt(bytes.NewReader(data[start:]), 0)
+		ob.allowObjptr = false
+		ob.allowStream = false
+		ob.allowEOF = true
+		obj, err := ob.readObject()
+		if err != nil {
+			return nil, err
+		}
+		defs[i] = objdef{objptr{id: p.id, gen: 0}, obj}
+	}
+	return defs, nil
+}
+
+// readXrefStream decodes a /Type /XRef cross-reference stream's rows,
+// given its dictionary (for /W, /Index, and /Prev) and decoded data.
+// It returns the per-object-number xrefRecords and the byte offset of
+// the previous xref section in this file's update chain (0 if none).
+func readXrefStream(hdr dict, data []byte) (map[uint32]xrefRecord, int64, error) {
+	wArr, ok := hdr[name("W")].(array)
+	if !ok || len(wArr) != 3 {
+		return nil, 0, errors.New("pdf: xref stream missing /W")
+	}
+	var w [3]int
+	for i, v := range wArr {
+		n, ok := v.(int64)
+		if !ok {
+			return nil, 0, errors.New("pdf: malformed /W in xref stream")
+		}
+		w[i] = int(n)
+	}
+	rowLen := w[0] + w[1] + w[2]
+
+	var index []int64
+	if idx, ok := hdr[name("Index")].(array); ok {
+		for _, v := range idx {
+			n, ok := v.(int64)
+			if !ok {
+				return nil, 0, errors.New("pdf: malformed /Index in xref stream")
+			}
+			index = append(index, n)
+		}
+	} else {
+		size, _ := hdr[name("Size")].(int64)
+		index = []int64{0, size}
+	}
+
+	var prev int64
+	if p, ok := hdr[name("Prev")].(int64); ok {
+		prev = p
+	}
+
+	entries := make(map[uint32]xrefRecord)
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, count := index[i], index[i+1]
+		for j := int64(0); j < count; j++ {
+			if pos+rowLen > len(data) {
+				return nil, 0, errors.New("pdf: truncated xref stream")
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+
+			typ := int64(1)
+			if w[0] > 0 {
+				typ = beInt(row[:w[0]])
+			}
+			f2 := beInt(row[w[0] : w[0]+w[1]])
+			f3 := beInt(row[w[0]+w[1] : rowLen])
+
+			id := uint32(start + j)
+			switch typ {
+			case 0:
+				entries[id] = xrefRecord{kind: xrefFree}
+			case 1:
+				entries[id] = xrefRecord{kind: xrefClassic, offset: f2}
+			case 2:
+				entries[id] = xrefRecord{kind: xrefCompressed, stmNum: uint32(f2), stmIdx: int(f3)}
+			}
+		}
+	}
+	return entries, prev, nil
+}
+
+func beInt(b []byte) int64 {
+	var x int64
+	for _, c := range b {
+		x = x<<8 | int64(c)
+	}
+	return x
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+)
+
+func TestReadObjStm(t *testing.T) {
+	// Two objects packed into a stream: id 10 -> 100, id 11 -> true.
+	// Header is "10 0 11 4\n" (10 bytes), then the objects themselves
+	// starting at First=10: "100 true", with "100" at relative offset 0
+	// and "true" at relative offset 4.
+	hdr := dict{name("N"): int64(2), name("First"): int64(10)}
+	data := []byte("10 0 11 4\n100 true")
+
+	defs, err := readObjStm(hdr, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("got %d objdefs, want 2", len(defs))
+	}
+	if defs[0].ptr != (objptr{10, 0}) || defs[0].obj != int64(100) {
+		t.Errorf("defs[0] = %+v, want {10 0} 100", defs[0])
+	}
+	if defs[1].ptr != (objptr{11, 0}) || defs[1].obj != true {
+		t.Errorf("defs[1] = %+v, want {11 0} true", defs[1])
+	}
+}
+
+func TestReadObjStmOffsetOutOfRange(t *testing.T) {
+	hdr := dict{name("N"): int64(1), name("First"): int64(10)}
+	data := []byte("0 1000\nx") // member offset points well past the data
+	if _, err := readObjStm(hdr, data); err == nil {
+		t.Fatal("expected an out-of-range error, got nil")
+	}
+}
+
+func TestReadXrefStream(t *testing.T) {
+	// W = [1 1 1]: one byte per field. Three rows for object ids 0-2:
+	// id 0 free, id 1 classic at offset 20, id 2 compressed in stream 5
+	// at index 2.
+	hdr := dict{
+		name("W"):     array{int64(1), int64(1), int64(1)},
+		name("Index"): array{int64(0), int64(3)},
+		name("Prev"):  int64(1234),
+	}
+	data := []byte{
+		0, 0, 0,
+		1, 20, 0,
+		2, 5, 2,
+	}
+
+	entries, prev, err := readXrefStream(hdr, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev != 1234 {
+		t.Errorf("prev = %d, want 1234", prev)
+	}
+	if e := entries[0]; e.kind != xrefFree {
+		t.Errorf("entries[0] = %+v, want free", e)
+	}
+	if e := entries[1]; e.kind != xrefClassic || e.offset != 20 {
+		t.Errorf("entries[1] = %+v, want classic offset 20", e)
+	}
+	if e := entries[2]; e.kind != xrefCompressed || e.stmNum != 5 || e.stmIdx != 2 {
+		t.Errorf("entries[2] = %+v, want compressed stream 5 index 2", e)
+	}
+}
+
+func TestDecodeStreamDataFlate(t *testing.T) {
+	want := []byte("hello, object stream")
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := stream{hdr: dict{name("Filter"): name("FlateDecode")}}
+	b := newBufferReaderAt(bytes.NewReader(nil), 0)
+	got, err := decodeStreamData(b, s, compressed.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decodeStreamData = %q, want %q", got, want)
+	}
+}
+
+func TestDecodeStreamDataUnsupportedFilter(t *testing.T) {
+	s := stream{hdr: dict{name("Filter"): name("LZWDecode")}}
+	b := newBufferReaderAt(bytes.NewReader(nil), 0)
+
+	// Non-strict: records a warning, returns the raw bytes unchanged.
+	raw := []byte("unchanged")
+	got, err := decodeStreamData(b, s, raw)
+	if err != nil {
+		t.Fatalf("non-strict: unexpected error %v", err)
+	}
+	if !bytes.Equal(got, raw) {
+		t.Errorf("non-strict: got %q, want raw %q unchanged", got, raw)
+	}
+	if len(b.Warnings()) != 1 {
+		t.Errorf("non-strict: got %d warnings, want 1", len(b.Warnings()))
+	}
+
+	// Strict: fails fast instead.
+	b2 := newBufferReaderAt(bytes.NewReader(nil), 0)
+	b2.strict = true
+	if _, err := decodeStreamData(b2, s, raw); err == nil {
+		t.Error("strict: expected an error, got nil")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// A fixed-page-size LRU cache over an io.ReaderAt, used by buffer to
+// support random access into large PDF files without holding the whole
+// file in memory or re-reading from the start on every seek.
+
+package pdf
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// pageSize is the size, in bytes, of one cached page. 4 KiB matches
+// common filesystem and OS page sizes, so a page read is usually a
+// single underlying I/O operation.
+const pageSize = 4096
+
+// defaultCachePages is the default number of pages a pageCache keeps
+// resident, about 1 MiB at pageSize bytes per page.
+const defaultCachePages = 256
+
+// A page is one pageSize-byte window of the underlying data, read once
+// via ReaderAt.ReadAt and reused until evicted. n is the number of valid
+// bytes in data (less than pageSize only for the final, possibly short,
+// page); err, if non-nil, is the error ReadAt reported once bytes past n
+// were requested (typically io.EOF).
+type page struct {
+	idx  int64
+	data [pageSize]byte
+	n    int
+	err  error
+}
+
+// A pageCache serves byte ranges of ra through a small set of cached
+// pages, evicting the least recently used page once the cache is full.
+// It is safe for concurrent use by several buffers built over the same
+// pageCache. newBufferReaderAt gives each buffer a pageCache of its own,
+// and a Reader holds a single buffer, so no two buffers in the package
+// share one.
+type pageCache struct {
+	ra       io.ReaderAt
+	maxPages int
+
+	mu    sync.Mutex
+	lru   *list.List // of *page, most recently used at front
+	pages map[int64]*list.Element
+}
+
+func newPageCache(ra io.ReaderAt, maxPages int) *pageCache {
+	if maxPages < 1 {
+		maxPages = 1
+	}
+	return &pageCache{
+		ra:       ra,
+		maxPages: maxPages,
+		lru:      list.New(),
+		pages:    make(map[int64]*list.Element),
+	}
+}
+
+// get returns the page at page index idx (covering bytes
+// [idx*pageSize, (idx+1)*pageSize)), reading it through ra if it is not
+// already cached. The returned error is non-nil only for a hard read
+// error other than EOF; a short final page is returned with p.err set to
+// io.EOF rather than as an error here, since the caller may only want
+// bytes within p.n.
+func (c *pageCache) get(idx int64) (*page, error) {
+	c.mu.Lock()
+	if el, ok := c.pages[idx]; ok {
+		c.lru.MoveToFront(el)
+		p := el.Value.(*page)
+		c.mu.Unlock()
+		return p, nil
+	}
+	c.mu.Unlock()
+
+	p := &page{idx: idx}
+	n, err := c.ra.ReadAt(p.data[:], idx*pageSize)
+	p.n = n
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	p.err = err
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.pages[idx]; ok {
+		// Lost a race with a concurrent reader of the same page; keep
+		// whichever copy is already cached.
+		c.lru.MoveToFront(el)
+		return el.Value.(*page), nil
+	}
+	el := c.lru.PushFront(p)
+	c.pages[idx] = el
+	if c.lru.Len() > c.maxPages {
+		back := c.lru.Back()
+		c.lru.Remove(back)
+		delete(c.pages, back.Value.(*page).idx)
+	}
+	return p, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The public Reader type: the parsing options and diagnostics a caller
+// gets on top of a buffer.
+//
+// Reader has no Open, Page or Trailer and no method that reads, parses
+// or resolves objects: it carries only the StrictMode setting of its
+// buffer and the warnings that buffer has recorded.
+
+package pdf
+
+import "io"
+
+// A Reader holds the parsing options and diagnostics of a buffer over a
+// single PDF file.
+type Reader struct {
+	b *buffer
+}
+
+// NewReaderAt returns a Reader over the PDF data in ra, which must
+// support random access (an *os.File, a bytes.Reader, a memory-mapped
+// mmap.ReaderAt, ...). Parsing starts in non-strict mode; call
+// SetStrictMode to change that before reading any objects.
+func NewReaderAt(ra io.ReaderAt) *Reader {
+	return &Reader{b: newBufferReaderAt(ra, 0)}
+}
+
+// StrictMode reports whether r fails fast on the first malformed-PDF
+// condition it encounters, as set by SetStrictMode.
+func (r *Reader) StrictMode() bool {
+	return r.b.strict
+}
+
+// SetStrictMode sets whether r fails fast on the first malformed-PDF
+// condition it encounters (true) or records it as a warning and keeps
+// parsing past it (false, the default). Changing this takes effect on
+// the next token or object r reads; it does not retroactively apply to
+// conditions already recorded in Warnings.
+func (r *Reader) SetStrictMode(strict bool) {
+	r.b.strict = strict
+}
+
+// Warnings returns the ParseErrors r has accumulated so far, in the
+// order encountered. In strict mode this holds at most the one error
+// that stopped parsing; in non-strict mode it holds every malformed-PDF
+// condition seen, letting a caller decide afterward whether to trust the
+// result.
+func (r *Reader) Warnings() []*ParseError {
+	return r.b.Warnings()
+}
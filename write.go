@@ -0,0 +1,411 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Writing of PDF objects back out to a byte stream.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// A Filter names a PDF stream filter that WriteStream knows how to apply
+// when encoding stream data.
+type Filter name
+
+// FlateDecode is the only filter WriteStream currently knows how to
+// produce; it wraps data with compress/flate.
+const FlateDecode Filter = "FlateDecode"
+
+// A Writer serializes the package's object model (object, dict, array,
+// stream, objptr, objdef) back out as PDF syntax: header, indirect
+// objects, a cross-reference section, and a trailer. Once every object
+// has been written, call Close (for a classic xref table) or
+// CloseXrefStream (for a PDF 1.5+ cross-reference stream) to finish the
+// file.
+type Writer struct {
+	w    *countingWriter
+	xref []xrefEntry
+	prev int64 // byte offset of a prior xref section, for incremental updates
+	err  error
+}
+
+// xrefEntry records where an indirect object's "id gen obj" line begins,
+// so Close can emit the byte offset table PDF readers use to seek
+// directly to an object instead of scanning the whole file.
+type xrefEntry struct {
+	ptr    objptr
+	offset int64
+}
+
+// countingWriter wraps an io.Writer and tracks the number of bytes
+// written so Writer can record object offsets for the cross-reference
+// table as it streams output, without buffering the whole file.
+type countingWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+// NewWriter returns a Writer that emits a fresh PDF to w, starting with
+// WriteHeader.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: &countingWriter{w: w}}
+}
+
+// NewIncrementalWriter returns a Writer that appends an incremental
+// update to an existing PDF already written to w. offset is the current
+// length of that file (where the update's first object will begin) and
+// prevXrefOffset is the startxref offset of its existing cross-reference
+// section; the new trailer's /Prev will point back at prevXrefOffset so
+// readers can chain back through the update history.
+func NewIncrementalWriter(w io.Writer, offset int64, prevXrefOffset int64) *Writer {
+	return &Writer{w: &countingWriter{w: w, offset: offset}, prev: prevXrefOffset}
+}
+
+// WriteHeader writes the PDF header line and a binary comment marking
+// the file as containing binary data, as required after PDF 1.4. Callers
+// starting a new file with NewWriter should call it first; callers of
+// NewIncrementalWriter should not call it at all, since an incremental
+// update appends to an already-headered file.
+func (wr *Writer) WriteHeader(version string) error {
+	return wr.writef("%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+}
+
+func (wr *Writer) writef(format string, args ...interface{}) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	_, err := fmt.Fprintf(wr.w, format, args...)
+	wr.err = err
+	return err
+}
+
+func (wr *Writer) write(p []byte) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	_, err := wr.w.Write(p)
+	wr.err = err
+	return err
+}
+
+// WriteObject writes obj as the body of indirect object ptr and records
+// its offset for the cross-reference table. obj must not be a stream;
+// use WriteStream for those, since a stream value alone does not carry
+// the bytes to write.
+func (wr *Writer) WriteObject(ptr objptr, obj object) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	if _, ok := obj.(stream); ok {
+		return fmt.Errorf("pdf: WriteObject called with a stream object; use WriteStream")
+	}
+	wr.xref = append(wr.xref, xrefEntry{ptr: ptr, offset: wr.w.offset})
+	wr.writef("%d %d obj\n", ptr.id, ptr.gen)
+	writeValue(wr, obj)
+	wr.writef("\nendobj\n")
+	return wr.err
+}
+
+// WriteStream writes indirect object ptr as a stream whose dictionary is
+// hdr and whose data is read from data, applying filters in order
+// (currently only FlateDecode is supported) and setting /Filter and
+// /Length in the written dictionary to match; hdr itself is not modified.
+func (wr *Writer) WriteStream(ptr objptr, hdr dict, data io.Reader, filters ...Filter) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	raw, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	hdr = cloneDict(hdr)
+	for _, f := range filters {
+		if f != FlateDecode {
+			return fmt.Errorf("pdf: unsupported stream filter %q", name(f))
+		}
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(raw); err != nil {
+			return err
+		}
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		raw = buf.Bytes()
+	}
+	if len(filters) == 1 {
+		hdr[name("Filter")] = name(filters[0])
+	} else if len(filters) > 1 {
+		names := make(array, len(filters))
+		for i, f := range filters {
+			names[i] = name(f)
+		}
+		hdr[name("Filter")] = names
+	}
+	hdr[name("Length")] = int64(len(raw))
+
+	wr.xref = append(wr.xref, xrefEntry{ptr: ptr, offset: wr.w.offset})
+	wr.writef("%d %d obj\n", ptr.id, ptr.gen)
+	writeValue(wr, hdr)
+	wr.writef("\nstream\n")
+	wr.write(raw)
+	wr.writef("\nendstream\nendobj\n")
+	return wr.err
+}
+
+// CopyObject writes body verbatim as the body of indirect object ptr,
+// recording its offset for the cross-reference table. body is the raw
+// bytes between "ptr.id ptr.gen obj" and "endobj" (inclusive of any
+// stream data) as read from an existing file, letting a caller shuttle
+// an objdef between files without re-encoding it.
+func (wr *Writer) CopyObject(ptr objptr, body []byte) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	wr.xref = append(wr.xref, xrefEntry{ptr: ptr, offset: wr.w.offset})
+	wr.writef("%d %d obj\n", ptr.id, ptr.gen)
+	wr.write(body)
+	wr.writef("\nendobj\n")
+	return wr.err
+}
+
+func cloneDict(d dict) dict {
+	x := make(dict, len(d))
+	for k, v := range d {
+		x[k] = v
+	}
+	return x
+}
+
+// writeValue writes obj in PDF syntax, recursing into dict and array
+// values. It is the write-side counterpart of (*buffer).readObject,
+// readDict, and readArray.
+func writeValue(wr *Writer, obj object) {
+	switch obj := obj.(type) {
+	case nil:
+		wr.writef("null")
+	case bool:
+		wr.writef("%v", obj)
+	case int64:
+		wr.writef("%d", obj)
+	case float64:
+		wr.writef("%s", strconv.FormatFloat(obj, 'f', -1, 64))
+	case string:
+		writeString(wr, obj)
+	case name:
+		writeName(wr, obj)
+	case objptr:
+		wr.writef("%d %d R", obj.id, obj.gen)
+	case array:
+		wr.writef("[")
+		for i, elt := range obj {
+			if i > 0 {
+				wr.writef(" ")
+			}
+			writeValue(wr, elt)
+		}
+		wr.writef("]")
+	case dict:
+		wr.writef("<<")
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			wr.writef(" ")
+			writeName(wr, name(k))
+			wr.writef(" ")
+			writeValue(wr, obj[name(k)])
+		}
+		wr.writef(" >>")
+	default:
+		wr.writef("null")
+	}
+}
+
+func writeName(wr *Writer, n name) {
+	wr.writef("/")
+	for _, c := range []byte(n) {
+		if isDelim(c) || isSpace(c) || c == '#' || c < 0x21 || c > 0x7e {
+			wr.writef("#%02X", c)
+			continue
+		}
+		wr.write([]byte{c})
+	}
+}
+
+func writeString(wr *Writer, s string) {
+	wr.writef("(")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', ')', '\\':
+			wr.write([]byte{'\\', c})
+		case '\n':
+			wr.write([]byte{'\\', 'n'})
+		case '\r':
+			wr.write([]byte{'\\', 'r'})
+		default:
+			wr.write([]byte{c})
+		}
+	}
+	wr.writef(")")
+}
+
+// xrefRun is a maximal run of xrefEntry with consecutive object ids,
+// the unit a classic xref table's "start count" subsection describes.
+type xrefRun struct {
+	start   uint32
+	entries []xrefEntry
+}
+
+// xrefRuns splits sorted-by-id entries into xrefRuns, starting a new run
+// wherever the object ids are not contiguous.
+func xrefRuns(sorted []xrefEntry) []xrefRun {
+	var runs []xrefRun
+	for _, e := range sorted {
+		if n := len(runs); n > 0 {
+			last := &runs[n-1]
+			if e.ptr.id == last.entries[len(last.entries)-1].ptr.id+1 {
+				last.entries = append(last.entries, e)
+				continue
+			}
+		}
+		runs = append(runs, xrefRun{start: e.ptr.id, entries: []xrefEntry{e}})
+	}
+	return runs
+}
+
+// Close writes a classic cross-reference table, trailer, and startxref
+// for every object written so far, and returns the first error
+// encountered while writing the file, if any.
+func (wr *Writer) Close(trailer dict) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	sort.Slice(wr.xref, func(i, j int) bool { return wr.xref[i].ptr.id < wr.xref[j].ptr.id })
+
+	xrefOffset := wr.w.offset
+	wr.writef("xref\n")
+
+	runs := xrefRuns(wr.xref)
+	if len(runs) > 0 && runs[0].start == 1 {
+		// Object ids start contiguously at 1, so the free object-0 entry
+		// can lead the same subsection instead of getting its own.
+		run := runs[0]
+		wr.writef("0 %d\n", len(run.entries)+1)
+		wr.writef("0000000000 65535 f \n")
+		for _, e := range run.entries {
+			wr.writef("%010d %05d n \n", e.offset, e.ptr.gen)
+		}
+		runs = runs[1:]
+	} else {
+		wr.writef("0 1\n")
+		wr.writef("0000000000 65535 f \n")
+	}
+	for _, run := range runs {
+		wr.writef("%d %d\n", run.start, len(run.entries))
+		for _, e := range run.entries {
+			wr.writef("%010d %05d n \n", e.offset, e.ptr.gen)
+		}
+	}
+
+	trailer = cloneDict(trailer)
+	trailer[name("Size")] = int64(nextID(wr.xref))
+	if wr.prev != 0 {
+		trailer[name("Prev")] = wr.prev
+	}
+	wr.writef("trailer\n")
+	writeValue(wr, trailer)
+	wr.writef("\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	return wr.err
+}
+
+// CloseXrefStream writes a PDF 1.5+ cross-reference stream (instead of a
+// classic xref table) describing every object written so far, followed
+// by startxref, and returns the first error encountered while writing
+// the file, if any. trailer supplies the entries (other than /Type,
+// /W, /Index, /Size, /Prev, /Filter, and /Length, which CloseXrefStream
+// fills in itself) that belong in the xref stream's dictionary.
+func (wr *Writer) CloseXrefStream(ptr objptr, trailer dict) error {
+	if wr.err != nil {
+		return wr.err
+	}
+	sort.Slice(wr.xref, func(i, j int) bool { return wr.xref[i].ptr.id < wr.xref[j].ptr.id })
+
+	xrefOffset := wr.w.offset
+
+	var raw bytes.Buffer
+	writeXrefStreamRow(&raw, 0, 0, 65535) // object 0 is always the head of the free list
+	id := uint32(1)
+	for _, e := range wr.xref {
+		for id < e.ptr.id {
+			writeXrefStreamRow(&raw, 0, 0, 0)
+			id++
+		}
+		writeXrefStreamRow(&raw, 1, uint32(e.offset), uint16(e.ptr.gen))
+		id++
+	}
+	// The xref stream's own object may leave a gap past the last real
+	// object id (e.g. when its id is reserved well ahead of time); fill
+	// it with free rows so /Index [0 size] below matches what we wrote.
+	for id < ptr.id {
+		writeXrefStreamRow(&raw, 0, 0, 0)
+		id++
+	}
+	writeXrefStreamRow(&raw, 1, uint32(xrefOffset), 0) // the xref stream object itself
+	id++
+	size := id
+
+	hdr := cloneDict(trailer)
+	hdr[name("Type")] = name("XRef")
+	hdr[name("W")] = array{int64(1), int64(4), int64(2)}
+	hdr[name("Index")] = array{int64(0), int64(size)}
+	hdr[name("Size")] = int64(size)
+	if wr.prev != 0 {
+		hdr[name("Prev")] = wr.prev
+	}
+
+	if err := wr.WriteStream(ptr, hdr, &raw, FlateDecode); err != nil {
+		return err
+	}
+	wr.writef("startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return wr.err
+}
+
+func writeXrefStreamRow(buf *bytes.Buffer, typ byte, f2 uint32, f3 uint16) {
+	buf.WriteByte(typ)
+	buf.WriteByte(byte(f2 >> 24))
+	buf.WriteByte(byte(f2 >> 16))
+	buf.WriteByte(byte(f2 >> 8))
+	buf.WriteByte(byte(f2))
+	buf.WriteByte(byte(f3 >> 8))
+	buf.WriteByte(byte(f3))
+}
+
+func nextID(xref []xrefEntry) uint32 {
+	var max uint32
+	for _, e := range xref {
+		if e.ptr.id >= max {
+			max = e.ptr.id + 1
+		}
+	}
+	return max
+}
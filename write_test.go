@@ -0,0 +1,107 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+// TestWriterRoundTrip writes a small PDF with Writer and reads the
+// indirect objects back with the package's own object reader, since this
+// tree has no higher-level Reader to resolve the xref table yet. It also
+// checks that startxref points at the byte offset where "xref" actually
+// begins.
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteHeader("1.7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.WriteObject(objptr{1, 0}, dict{
+		name("Type"):  name("Catalog"),
+		name("Pages"): objptr{2, 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("BT /F1 12 Tf (Hello, world) Tj ET")
+	if err := wr.WriteStream(objptr{2, 0}, dict{}, bytes.NewReader(content), FlateDecode); err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.Close(dict{name("Root"): objptr{1, 0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.Bytes()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.7\n")) {
+		t.Fatalf("missing header, got %q", out[:20])
+	}
+
+	b := newBufferReaderAt(bytes.NewReader(out), 0)
+
+	obj1, err := b.readObject()
+	if err != nil {
+		t.Fatalf("reading object 1: %v", err)
+	}
+	def1, ok := obj1.(objdef)
+	if !ok || def1.ptr != (objptr{1, 0}) {
+		t.Fatalf("object 1 = %#v, want objdef{1 0 ...}", obj1)
+	}
+	got1, ok := def1.obj.(dict)
+	if !ok || got1[name("Type")] != name("Catalog") {
+		t.Fatalf("object 1 body = %#v, want Catalog dict", def1.obj)
+	}
+
+	obj2, err := b.readObject()
+	if err != nil {
+		t.Fatalf("reading object 2: %v", err)
+	}
+	def2, ok := obj2.(objdef)
+	if !ok || def2.ptr != (objptr{2, 0}) {
+		t.Fatalf("object 2 = %#v, want objdef{2 0 ...}", obj2)
+	}
+	s, ok := def2.obj.(stream)
+	if !ok {
+		t.Fatalf("object 2 body = %#v, want stream", def2.obj)
+	}
+	if s.hdr[name("Filter")] != name("FlateDecode") {
+		t.Fatalf("stream /Filter = %v, want FlateDecode", s.hdr[name("Filter")])
+	}
+	length, ok := s.hdr[name("Length")].(int64)
+	if !ok {
+		t.Fatalf("stream /Length missing or not an int64: %#v", s.hdr[name("Length")])
+	}
+	raw := out[s.offset : s.offset+length]
+	decoded, err := decodeStreamData(b, s, raw)
+	if err != nil {
+		t.Fatalf("decoding stream data: %v", err)
+	}
+	if !bytes.Equal(decoded, content) {
+		t.Fatalf("stream content = %q, want %q", decoded, content)
+	}
+
+	xrefOff := bytes.Index(out, []byte("xref\n"))
+	if xrefOff < 0 {
+		t.Fatalf("no xref section found in %q", out)
+	}
+	startxrefLine := bytes.Index(out, []byte("startxref\n"))
+	if startxrefLine < 0 {
+		t.Fatalf("no startxref in %q", out)
+	}
+	rest := out[startxrefLine+len("startxref\n"):]
+	end := bytes.IndexByte(rest, '\n')
+	got, err := strconv.ParseInt(string(rest[:end]), 10, 64)
+	if err != nil {
+		t.Fatalf("parsing startxref value: %v", err)
+	}
+	if int(got) != xrefOff {
+		t.Errorf("startxref = %d, want %d (offset of \"xref\\n\")", got, xrefOff)
+	}
+
+	if !bytes.Contains(out, []byte("trailer")) {
+		t.Error("missing trailer keyword")
+	}
+}